@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apiSocketPath returns the UNIX socket path the control API listens on,
+// preferring $XDG_RUNTIME_DIR and falling back to the OS temp dir so the
+// daemon still starts under e.g. a plain systemd user unit.
+func apiSocketPath() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = os.TempDir()
+	}
+	return filepath.Join(runtimeDir, "smarttype.sock")
+}
+
+// loadOrCreateAPIToken returns the token clients must present to the
+// control API, generating and persisting one (mode 0600, in the config
+// directory) on first run.
+func (s *Service) loadOrCreateAPIToken() (string, error) {
+	tokenPath := filepath.Join(filepath.Dir(s.configPath), "api.token")
+
+	data, err := os.ReadFile(tokenPath)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	if err := os.MkdirAll(filepath.Dir(tokenPath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(tokenPath, []byte(token), 0600); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// serveAPI runs the control API's HTTP server over a UNIX socket until
+// ctx is cancelled.
+func (s *Service) serveAPI(ctx context.Context) {
+	defer s.wg.Done()
+
+	socketPath := apiSocketPath()
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		s.log(subsystemAPI).Error("failed to start control API", "error", err)
+		return
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		s.log(subsystemAPI).Warn("failed to set control API socket permissions", "error", err)
+	}
+
+	server := &http.Server{Handler: s.apiHandler()}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(listener) }()
+
+	s.log(subsystemAPI).Info("control API listening", "socket", socketPath)
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		os.Remove(socketPath)
+	case err := <-serveErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log(subsystemAPI).Error("control API stopped unexpectedly", "error", err)
+		}
+		os.Remove(socketPath)
+	}
+}
+
+func (s *Service) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v1/stats", s.requireToken(s.handleStats))
+	mux.HandleFunc("/v1/history", s.requireToken(s.handleHistory))
+	mux.HandleFunc("/v1/config", s.requireToken(s.handleConfig))
+	mux.HandleFunc("/v1/pause", s.requireToken(s.handlePause))
+	mux.HandleFunc("/v1/resume", s.requireToken(s.handleResume))
+	mux.HandleFunc("/v1/typos", s.requireToken(s.handleTypos))
+	mux.HandleFunc("/v1/typos/", s.requireToken(s.handleTypos))
+	mux.HandleFunc("/v1/apps", s.requireToken(s.handleApps))
+	mux.HandleFunc("/v1/apps/", s.requireToken(s.handleApps))
+
+	return mux
+}
+
+// requireToken wraps an API handler with auth: the request must carry
+// "Authorization: Bearer <token>" matching the token on disk.
+func (s *Service) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := s.loadOrCreateAPIToken()
+		if err != nil {
+			http.Error(w, "token unavailable", http.StatusInternalServerError)
+			return
+		}
+
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if got == "" || got != token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (s *Service) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.GetStats())
+}
+
+// handleHistory returns the correction log written by
+// recordCorrection, optionally limited to the last N entries via
+// ?limit=N.
+func (s *Service) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := os.ReadFile(s.historyPath())
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(w, fmt.Sprintf("failed to read history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var entries []historyEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry historyEntry
+		if err := json.Unmarshal([]byte(line), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		var n int
+		if _, err := fmt.Sscanf(limit, "%d", &n); err == nil && n >= 0 && n < len(entries) {
+			entries = entries[len(entries)-n:]
+		}
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Service) handleConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.RLock()
+		cfg := s.config
+		s.mu.RUnlock()
+		writeJSON(w, http.StatusOK, cfg)
+
+	case http.MethodPut:
+		var cfg Config
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			http.Error(w, fmt.Sprintf("invalid config: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := validateConfig(&cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Gate the transition against the config actually live right now,
+		// before anything is mutated; by the time Reload below calls
+		// loadConfig, s.config already is cfg, so its own BeforeLoad check
+		// would only ever compare cfg against itself.
+		if err := s.checkBeforeLoad(&cfg); err != nil {
+			http.Error(w, fmt.Sprintf("rejected config: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		s.config = &cfg
+		s.mu.Unlock()
+
+		if err := s.saveConfig(); err != nil {
+			http.Error(w, fmt.Sprintf("failed to save config: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err := s.Reload(r.Context()); err != nil {
+			http.Error(w, fmt.Sprintf("failed to reload: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, cfg)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Service) handlePause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	s.paused = true
+	s.mu.Unlock()
+	s.pauseOrRestartHook(ipcMessage{Type: "pause"})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	s.paused = false
+	s.mu.Unlock()
+	s.pauseOrRestartHook(ipcMessage{Type: "resume"})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pauseOrRestartHook pushes a pause/resume message to the connected
+// hook, falling back to a full restart if no hook is connected over
+// IPC yet.
+func (s *Service) pauseOrRestartHook(msg ipcMessage) {
+	if err := s.pushIPC(msg); err == nil {
+		return
+	}
+	s.restartHook()
+}
+
+func (s *Service) handleTypos(w http.ResponseWriter, r *http.Request) {
+	from := strings.TrimPrefix(r.URL.Path, "/v1/typos/")
+
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.RLock()
+		typos := s.config.CustomTypos
+		s.mu.RUnlock()
+		writeJSON(w, http.StatusOK, typos)
+
+	case http.MethodPost:
+		var entry struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil || entry.From == "" {
+			http.Error(w, "invalid typo entry", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		if s.config.CustomTypos == nil {
+			s.config.CustomTypos = map[string]string{}
+		}
+		s.config.CustomTypos[entry.From] = entry.To
+		s.mu.Unlock()
+
+		if err := s.saveConfig(); err != nil {
+			http.Error(w, fmt.Sprintf("failed to save config: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if from == "" {
+			http.Error(w, "missing typo key in path", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		delete(s.config.CustomTypos, from)
+		s.mu.Unlock()
+
+		if err := s.saveConfig(); err != nil {
+			http.Error(w, fmt.Sprintf("failed to save config: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Service) handleApps(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/apps/")
+
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.RLock()
+		apps := s.config.Applications
+		s.mu.RUnlock()
+		writeJSON(w, http.StatusOK, apps)
+
+	case http.MethodPut:
+		if name == "" {
+			http.Error(w, "missing app name in path", http.StatusBadRequest)
+			return
+		}
+		var app AppConfig
+		if err := json.NewDecoder(r.Body).Decode(&app); err != nil {
+			http.Error(w, fmt.Sprintf("invalid app config: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		if s.config.Applications == nil {
+			s.config.Applications = map[string]AppConfig{}
+		}
+		s.config.Applications[name] = app
+		s.mu.Unlock()
+
+		if err := s.saveConfig(); err != nil {
+			http.Error(w, fmt.Sprintf("failed to save config: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, app)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// validateConfig rejects config transitions that would leave the daemon
+// in a broken state.
+func validateConfig(cfg *Config) error {
+	if cfg.MinWordLength < 1 {
+		return errors.New("min_word_length must be at least 1")
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// Source is a config origin that can be loaded and watched for changes.
+// Service.loadConfig merges sources in priority order: the base file,
+// then any conf.d includes named by its `include:` directive, then
+// environment variables (highest priority, since they're the one source
+// an operator can override per-invocation without touching a file).
+type Source interface {
+	Name() string
+	Load(ctx context.Context) ([]byte, error)
+	Watch(ctx context.Context) (<-chan struct{}, error)
+}
+
+// fileSource loads configuration from a single YAML file.
+type fileSource struct {
+	path string
+}
+
+func newFileSource(path string) *fileSource { return &fileSource{path: path} }
+
+func (f *fileSource) Name() string { return "file:" + f.path }
+
+func (f *fileSource) Load(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// Watch notifies on writes (or creation) of f.path within its parent
+// directory, and stops watching once ctx is cancelled.
+func (f *fileSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(f.path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("watch %s: %w", f.path, err)
+	}
+
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Name == f.path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					select {
+					case ch <- struct{}{}:
+					default:
+					}
+				}
+			case <-watcher.Errors:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// envSource overlays scalar settings from SMARTTYPE_* environment
+// variables on top of whatever the file sources produced.
+type envSource struct{}
+
+func (envSource) Name() string { return "env" }
+
+func (envSource) Load(ctx context.Context) ([]byte, error) {
+	cfg := Config{}
+	set := false
+
+	if v, ok := os.LookupEnv("SMARTTYPE_ENABLED"); ok {
+		val := v == "true" || v == "1"
+		cfg.Enabled = &val
+		set = true
+	}
+	if v, ok := os.LookupEnv("SMARTTYPE_SMART_PUNCTUATION"); ok {
+		val := v == "true" || v == "1"
+		cfg.SmartPunctuation = &val
+		set = true
+	}
+	if v, ok := os.LookupEnv("SMARTTYPE_AUTOCORRECT"); ok {
+		val := v == "true" || v == "1"
+		cfg.Autocorrect = &val
+		set = true
+	}
+	if v, ok := os.LookupEnv("SMARTTYPE_MIN_WORD_LENGTH"); ok {
+		var n int
+		if _, err := fmt.Sscanf(v, "%d", &n); err == nil {
+			cfg.MinWordLength = n
+			set = true
+		}
+	}
+	if v, ok := os.LookupEnv("SMARTTYPE_HOTKEY"); ok {
+		cfg.Hotkey = v
+		set = true
+	}
+
+	if !set {
+		return nil, nil
+	}
+	return yaml.Marshal(&cfg)
+}
+
+// Watch is a no-op: environment variables don't change for a running
+// process, so there's nothing to notify on.
+func (envSource) Watch(ctx context.Context) (<-chan struct{}, error) {
+	return make(chan struct{}), nil
+}
+
+// confDIncludes expands the `include:` glob patterns named in the base
+// config into file sources under ~/.config/smarttype/conf.d, sorted by
+// path so merge order is deterministic.
+func confDIncludes(configPath string, patterns []string) []Source {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	confDir := filepath.Join(filepath.Dir(configPath), "conf.d")
+
+	var matches []string
+	for _, pattern := range patterns {
+		found, err := filepath.Glob(filepath.Join(confDir, pattern))
+		if err != nil {
+			continue
+		}
+		matches = append(matches, found...)
+	}
+	sort.Strings(matches)
+
+	sources := make([]Source, 0, len(matches))
+	for _, path := range matches {
+		sources = append(sources, newFileSource(path))
+	}
+	return sources
+}
+
+// mergeConfig layers src on top of dst: CustomTypos and Applications are
+// merged key-wise, scalars are overridden when src sets a non-zero
+// value. src is the higher-priority layer; a field it leaves unset (its
+// zero value) never clobbers the value dst got from the layers beneath.
+// Enabled, SmartPunctuation and Autocorrect are *bool specifically so
+// that "unset" and "explicitly false" are distinguishable here: only a
+// non-nil src pointer overrides dst, so a source can turn a setting off
+// as well as on.
+func mergeConfig(dst, src *Config) {
+	if src == nil {
+		return
+	}
+
+	if src.Enabled != nil {
+		dst.Enabled = src.Enabled
+	}
+	if src.SmartPunctuation != nil {
+		dst.SmartPunctuation = src.SmartPunctuation
+	}
+	if src.Autocorrect != nil {
+		dst.Autocorrect = src.Autocorrect
+	}
+	if src.MinWordLength != 0 {
+		dst.MinWordLength = src.MinWordLength
+	}
+	if src.Hotkey != "" {
+		dst.Hotkey = src.Hotkey
+	}
+	if len(src.Include) != 0 {
+		dst.Include = src.Include
+	}
+	if src.Notifications.Enabled || len(src.Notifications.Sinks) != 0 {
+		dst.Notifications = src.Notifications
+	}
+	if src.Logging.Level != "" {
+		dst.Logging.Level = src.Logging.Level
+	}
+	if src.Logging.Format != "" {
+		dst.Logging.Format = src.Logging.Format
+	}
+	for name, level := range src.Logging.Subsystems {
+		if dst.Logging.Subsystems == nil {
+			dst.Logging.Subsystems = map[string]string{}
+		}
+		dst.Logging.Subsystems[name] = level
+	}
+
+	for name, app := range src.Applications {
+		if dst.Applications == nil {
+			dst.Applications = map[string]AppConfig{}
+		}
+		dst.Applications[name] = mergeAppConfig(dst.Applications[name], app)
+	}
+	for from, to := range src.CustomTypos {
+		if dst.CustomTypos == nil {
+			dst.CustomTypos = map[string]string{}
+		}
+		dst.CustomTypos[from] = to
+	}
+}
+
+// mergeAppConfig layers src's explicitly-set fields over dst, so a
+// conf.d snippet overriding one field of an app (e.g. just SmartQuotes)
+// leaves the app's other fields untouched instead of resetting them to
+// zero values.
+func mergeAppConfig(dst, src AppConfig) AppConfig {
+	if src.Enabled != nil {
+		dst.Enabled = src.Enabled
+	}
+	if src.SmartQuotes != nil {
+		dst.SmartQuotes = src.SmartQuotes
+	}
+	if src.Autocorrect != nil {
+		dst.Autocorrect = src.Autocorrect
+	}
+	return dst
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestMergeConfigScalarsCanTurnOff(t *testing.T) {
+	dst := &Config{Enabled: boolPtr(true), SmartPunctuation: boolPtr(true)}
+	src := &Config{Enabled: boolPtr(false)}
+
+	mergeConfig(dst, src)
+
+	if got := boolValue(dst.Enabled, true); got != false {
+		t.Errorf("Enabled = %v, want false (src should be able to disable)", got)
+	}
+	if got := boolValue(dst.SmartPunctuation, false); got != true {
+		t.Errorf("SmartPunctuation = %v, want true (unset src field must not clobber dst)", got)
+	}
+}
+
+func TestMergeConfigScalarsLeaveUnsetAlone(t *testing.T) {
+	dst := &Config{Enabled: boolPtr(true)}
+	src := &Config{}
+
+	mergeConfig(dst, src)
+
+	if got := boolValue(dst.Enabled, false); got != true {
+		t.Errorf("Enabled = %v, want true (src never set this field)", got)
+	}
+}
+
+func TestMergeConfigApplicationsMergesFieldwise(t *testing.T) {
+	dst := &Config{
+		Applications: map[string]AppConfig{
+			"kitty": {Enabled: boolPtr(true), Autocorrect: boolPtr(true)},
+		},
+	}
+	src := &Config{
+		Applications: map[string]AppConfig{
+			"kitty": {SmartQuotes: boolPtr(false)},
+		},
+	}
+
+	mergeConfig(dst, src)
+
+	kitty := dst.Applications["kitty"]
+	if got := boolValue(kitty.Enabled, false); got != true {
+		t.Errorf("kitty.Enabled = %v, want true (untouched by src's partial override)", got)
+	}
+	if got := boolValue(kitty.Autocorrect, false); got != true {
+		t.Errorf("kitty.Autocorrect = %v, want true (untouched by src's partial override)", got)
+	}
+	if got := boolValue(kitty.SmartQuotes, true); got != false {
+		t.Errorf("kitty.SmartQuotes = %v, want false (set by src)", got)
+	}
+}
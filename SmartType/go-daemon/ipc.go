@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ipcMessage is one line of the newline-delimited JSON protocol spoken
+// between the daemon and the Rust input hook over the socket named by
+// SMARTTYPE_IPC_SOCK. Only the fields relevant to Type are populated.
+// Payload reuses Config's json tags, so the hook sees the same lowercase
+// field names ("min_word_length", "custom_typos", ...) as everywhere else
+// in this protocol; a Config pushed here without explicit json tags would
+// encode as mismatched PascalCase and the hook would silently ignore it.
+type ipcMessage struct {
+	Type    string  `json:"type"`
+	Version string  `json:"version,omitempty"`
+	App     string  `json:"app,omitempty"`
+	From    string  `json:"from,omitempty"`
+	To      string  `json:"to,omitempty"`
+	TS      int64   `json:"ts,omitempty"`
+	Payload *Config `json:"payload,omitempty"`
+}
+
+// historyEntry is one line of the correction audit log consumed
+// by GET /v1/history.
+type historyEntry struct {
+	App  string    `json:"app"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// ipcSocketPath returns the UNIX socket path the hook connects to,
+// preferring $XDG_RUNTIME_DIR like apiSocketPath so the socket lives
+// somewhere only this user can traverse, not the world-readable OS temp
+// dir.
+func ipcSocketPath() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = os.TempDir()
+	}
+	return filepath.Join(runtimeDir, fmt.Sprintf("smarttype-ipc-%d.sock", os.Getpid()))
+}
+
+// serveIPC accepts the hook's connection on the IPC socket and processes
+// incoming messages until ctx is cancelled. Only one hook connection is
+// expected at a time; a new connection replaces the previous one, so the
+// socket is chmod'd 0600 (as apiSocketPath's listener is) to stop any
+// other local user from connecting and hijacking that slot.
+func (s *Service) serveIPC(ctx context.Context) {
+	defer s.wg.Done()
+
+	os.Remove(s.ipcSockPath)
+	listener, err := net.Listen("unix", s.ipcSockPath)
+	if err != nil {
+		s.log(subsystemIPC).Error("failed to start IPC listener", "error", err)
+		return
+	}
+	if err := os.Chmod(s.ipcSockPath, 0600); err != nil {
+		s.log(subsystemIPC).Warn("failed to set IPC socket permissions", "error", err)
+	}
+	defer os.Remove(s.ipcSockPath)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				s.log(subsystemIPC).Error("IPC accept error", "error", err)
+				continue
+			}
+		}
+
+		s.setIPCConn(conn)
+		go s.handleIPCConn(conn)
+	}
+}
+
+func (s *Service) setIPCConn(conn net.Conn) {
+	s.ipcMu.Lock()
+	if s.ipcConn != nil {
+		s.ipcConn.Close()
+	}
+	s.ipcConn = conn
+	s.ipcMu.Unlock()
+}
+
+func (s *Service) handleIPCConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var msg ipcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			s.log(subsystemIPC).Warn("malformed IPC message", "error", err)
+			continue
+		}
+		s.handleIPCMessage(msg)
+	}
+
+	s.ipcMu.Lock()
+	if s.ipcConn == conn {
+		s.ipcConn = nil
+	}
+	s.ipcMu.Unlock()
+}
+
+func (s *Service) handleIPCMessage(msg ipcMessage) {
+	switch msg.Type {
+	case "hello":
+		s.log(subsystemIPC).Info("hook connected over IPC", "version", msg.Version)
+	case "correction":
+		s.recordCorrection(msg.App, msg.From, msg.To)
+	default:
+		s.log(subsystemIPC).Warn("unknown IPC message type", "type", msg.Type)
+	}
+}
+
+// recordCorrection updates in-memory stats and appends to the history
+// log used by GET /v1/history. The log is never rotated or truncated;
+// it grows by one line per correction.
+func (s *Service) recordCorrection(app, from, to string) {
+	s.mu.Lock()
+	s.stats.TotalCorrections++
+	s.stats.SessionCorrections++
+	s.mu.Unlock()
+
+	s.publish(EventCorrection, "Correction applied", fmt.Sprintf("%s: %q -> %q", app, from, to))
+
+	entry := historyEntry{App: app, From: from, To: to, Time: time.Now()}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(s.historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		s.log(subsystemIPC).Error("failed to append to history log", "error", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+func (s *Service) historyPath() string {
+	return filepath.Join(filepath.Dir(s.configPath), "history.jsonl")
+}
+
+// errIPCNotConnected is returned by pushIPC when no hook is currently
+// connected over IPC, distinguishing that case from a write error so
+// callers can always fall back to restartHook correctly. Checking
+// connectedness and pushing must happen under one lock acquisition here
+// rather than as two separate calls, or the hook can disconnect in the
+// window between them and the push is silently dropped.
+var errIPCNotConnected = errors.New("smarttype: no hook connected over IPC")
+
+// pushIPC sends a message to the connected hook, if any, returning
+// errIPCNotConnected if none is.
+func (s *Service) pushIPC(msg ipcMessage) error {
+	s.ipcMu.RLock()
+	defer s.ipcMu.RUnlock()
+
+	if s.ipcConn == nil {
+		return errIPCNotConnected
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = s.ipcConn.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPushIPCNotConnected(t *testing.T) {
+	s := &Service{}
+
+	err := s.pushIPC(ipcMessage{Type: "pause"})
+	if !errors.Is(err, errIPCNotConnected) {
+		t.Errorf("pushIPC() with no hook connected = %v, want errIPCNotConnected so callers can fall back to restartHook", err)
+	}
+}
@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/hashicorp/go-hclog"
 	"github.com/sevlyar/go-daemon"
 )
 
+// shutdownTimeout bounds how long we wait for supervised children to
+// unwind after SIGTERM/SIGINT before exiting anyway.
+const shutdownTimeout = 5 * time.Second
+
+// bootLog handles messages before the service (and its configured,
+// per-subsystem loggers) exists.
+var bootLog = hclog.Default().Named("main")
+
 var (
 	signal_flag = flag.String("s", "", "send signal to daemon (stop, reload)")
 	daemonize   = flag.Bool("d", false, "run as daemon")
@@ -33,7 +43,8 @@ func main() {
 	if len(*signal_flag) > 0 {
 		daemon_process, err := cntxt.Search()
 		if err != nil {
-			log.Fatalf("Unable to send signal to daemon: %s", err.Error())
+			bootLog.Error("unable to send signal to daemon", "error", err)
+			os.Exit(1)
 		}
 
 		switch *signal_flag {
@@ -53,7 +64,8 @@ func main() {
 	if *daemonize {
 		d, err := cntxt.Reborn()
 		if err != nil {
-			log.Fatal("Unable to run as daemon: ", err)
+			bootLog.Error("unable to run as daemon", "error", err)
+			os.Exit(1)
 		}
 		if d != nil {
 			return
@@ -61,12 +73,20 @@ func main() {
 		defer cntxt.Release()
 	}
 
-	log.Println("SmartType daemon starting...")
+	bootLog.Info("SmartType daemon starting...")
+
+	// Run service. Its context is cancelled on SIGTERM/SIGINT, which
+	// unwinds the hook supervisor and config watcher in order.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// Run service
 	service := NewService()
-	if err := service.Start(); err != nil {
-		log.Fatal("Failed to start service: ", err)
+	service.RegisterBeforeLoad(func(old, new *Config) error {
+		return validateConfig(new)
+	})
+	if err := service.Start(ctx); err != nil {
+		bootLog.Error("failed to start service", "error", err)
+		os.Exit(1)
 	}
 
 	// Setup signal handling
@@ -78,13 +98,16 @@ func main() {
 		sig := <-sigChan
 		switch sig {
 		case syscall.SIGTERM, syscall.SIGINT:
-			log.Println("Received termination signal, shutting down...")
-			service.Stop()
+			service.log(subsystemService).Info("received termination signal, shutting down")
+			cancel()
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			service.Stop(shutdownCtx)
+			shutdownCancel()
 			return
 		case syscall.SIGHUP:
-			log.Println("Received reload signal, reloading configuration...")
-			if err := service.Reload(); err != nil {
-				log.Printf("Error reloading: %v", err)
+			service.log(subsystemService).Info("received reload signal, reloading configuration")
+			if err := service.Reload(ctx); err != nil {
+				service.log(subsystemService).Error("reload failed", "error", err)
 			}
 		}
 	}
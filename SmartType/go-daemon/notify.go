@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// NotifyEvent identifies the kind of thing a Notification is about, so
+// it can be matched against a NotificationConfig's event mask.
+type NotifyEvent string
+
+const (
+	EventStartup      NotifyEvent = "startup"
+	EventShutdown     NotifyEvent = "shutdown"
+	EventReloadFailed NotifyEvent = "reload_failed"
+	EventHookCrashed  NotifyEvent = "hook_crashed"
+	EventCorrection   NotifyEvent = "correction"
+)
+
+// Notification is one event published to the configured notifiers.
+type Notification struct {
+	Event NotifyEvent `json:"event"`
+	Title string      `json:"title"`
+	Body  string      `json:"body"`
+	Time  time.Time   `json:"time"`
+}
+
+// Notifier delivers a Notification to one sink (desktop, webhook, ...).
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NotificationConfig controls which sinks are active, which events they
+// receive, and how often they may fire.
+type NotificationConfig struct {
+	Enabled    bool     `yaml:"enabled" json:"enabled"`
+	Sinks      []string `yaml:"sinks,omitempty" json:"sinks,omitempty"`
+	WebhookURL string   `yaml:"webhook_url,omitempty" json:"webhook_url,omitempty"`
+	// RateLimit caps notifications per minute across all sinks; 0 means
+	// unlimited.
+	RateLimit int `yaml:"rate_limit_per_minute,omitempty" json:"rate_limit_per_minute,omitempty"`
+	// Events restricts delivery to the named events; empty means all.
+	Events []string `yaml:"events,omitempty" json:"events,omitempty"`
+}
+
+// publish queues a notification for delivery without blocking the
+// caller; if the queue is full the notification is dropped rather than
+// stalling the hot path.
+func (s *Service) publish(event NotifyEvent, title, body string) {
+	select {
+	case s.notifyCh <- Notification{Event: event, Title: title, Body: body, Time: time.Now()}:
+	default:
+		s.log(subsystemService).Warn("notification queue full, dropping event", "event", event)
+	}
+}
+
+// runNotifications drains published notifications and fans them out to
+// the configured notifiers until ctx is cancelled.
+func (s *Service) runNotifications(ctx context.Context) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case n := <-s.notifyCh:
+			s.mu.RLock()
+			cfg := s.config.Notifications
+			notifiers := s.notifiers
+			limiter := s.notifyLimiter
+			s.mu.RUnlock()
+
+			if !eventEnabled(cfg.Events, n.Event) {
+				continue
+			}
+			if limiter != nil && !limiter.Allow() {
+				continue
+			}
+
+			for _, notifier := range notifiers {
+				if err := notifier.Notify(ctx, n); err != nil {
+					s.log(subsystemService).Error("notifier failed", "notifier", notifier.Name(), "error", err)
+				}
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func eventEnabled(events []string, event NotifyEvent) bool {
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == string(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshNotifiers rebuilds the notifier set and rate limiter from the
+// current config. Called whenever config is (re)loaded.
+func (s *Service) refreshNotifiers() {
+	s.mu.RLock()
+	cfg := s.config.Notifications
+	s.mu.RUnlock()
+
+	var notifiers []Notifier
+	if cfg.Enabled {
+		for _, sink := range cfg.Sinks {
+			switch sink {
+			case "desktop":
+				notifiers = append(notifiers, desktopNotifier{})
+			case "webhook":
+				if cfg.WebhookURL != "" {
+					notifiers = append(notifiers, newWebhookNotifier(cfg.WebhookURL))
+				}
+			default:
+				s.log(subsystemService).Warn("unknown notification sink", "sink", sink)
+			}
+		}
+	}
+	if len(notifiers) == 0 {
+		notifiers = []Notifier{noopNotifier{}}
+	}
+
+	s.mu.Lock()
+	s.notifiers = notifiers
+	s.notifyLimiter = newRateLimiter(cfg.RateLimit)
+	s.mu.Unlock()
+}
+
+// noopNotifier is the default sink when notifications are disabled.
+type noopNotifier struct{}
+
+func (noopNotifier) Name() string                              { return "noop" }
+func (noopNotifier) Notify(ctx context.Context, n Notification) error { return nil }
+
+// desktopNotifier shows a desktop notification via notify-send.
+type desktopNotifier struct{}
+
+func (desktopNotifier) Name() string { return "desktop" }
+
+func (desktopNotifier) Notify(ctx context.Context, n Notification) error {
+	return exec.CommandContext(ctx, "notify-send", n.Title, n.Body).Run()
+}
+
+// webhookNotifier POSTs the notification as JSON to a configured URL.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookNotifier(url string) *webhookNotifier {
+	return &webhookNotifier{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (w *webhookNotifier) Name() string { return "webhook:" + w.url }
+
+func (w *webhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// rateLimiter caps events to max per one-minute window. The window is
+// fixed, not sliding: it restarts on the first call made more than a
+// minute after it began. A max of 0 disables the limit.
+type rateLimiter struct {
+	mu          sync.Mutex
+	max         int
+	count       int
+	windowStart time.Time
+}
+
+func newRateLimiter(maxPerMinute int) *rateLimiter {
+	return &rateLimiter{max: maxPerMinute, windowStart: time.Now()}
+}
+
+func (r *rateLimiter) Allow() bool {
+	if r.max <= 0 {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.windowStart) > time.Minute {
+		r.windowStart = time.Now()
+		r.count = 0
+	}
+	if r.count >= r.max {
+		return false
+	}
+	r.count++
+	return true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestReloadSerialized guards against the API-triggered Reload and the
+// watcher-triggered one racing each other: with reloadMu held for the
+// duration of loadConfig, concurrent Reload calls must never overlap.
+func TestReloadSerialized(t *testing.T) {
+	dir := t.TempDir()
+	s := &Service{configPath: filepath.Join(dir, "config.yaml"), notifyCh: make(chan Notification, 64)}
+
+	var inFlight int32
+	var overlapped int32
+
+	// The BeforeLoad callback runs inside loadConfig, so it observes any
+	// two Reload calls that are in loadConfig at the same time.
+	s.RegisterBeforeLoad(func(old, new *Config) error {
+		if atomic.AddInt32(&inFlight, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return nil
+	})
+
+	const n = 20
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			if err := s.Reload(context.Background()); err != nil {
+				t.Errorf("Reload() = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if overlapped != 0 {
+		t.Error("reloadMu did not serialize concurrent Reload calls")
+	}
+}
+
+func TestReloadCreatesDefaultConfigOnce(t *testing.T) {
+	dir := t.TempDir()
+	s := NewService()
+	s.configPath = filepath.Join(dir, "config.yaml")
+	s.notifyCh = make(chan Notification, 64)
+
+	if err := s.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() on first run = %v", err)
+	}
+	if _, err := os.Stat(s.configPath); err != nil {
+		t.Fatalf("expected default config to be written: %v", err)
+	}
+}
@@ -1,47 +1,108 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sync"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
+	"github.com/hashicorp/go-hclog"
 	"gopkg.in/yaml.v3"
 )
 
-// Service manages the SmartType daemon
+// BeforeLoadFunc is invoked with the current and candidate config before
+// a reload is applied. Returning an error rejects the transition and
+// keeps the old config in place.
+type BeforeLoadFunc func(old, new *Config) error
+
+// Service manages the SmartType daemon. Start/Stop/Reload are supervised
+// against a context: cancelling it (or calling Stop) unwinds the hook
+// supervisor and config watcher goroutines in order.
 type Service struct {
-	config       *Config
-	configPath   string
-	hookProcess  *os.Process
-	watcher      *fsnotify.Watcher
-	stopChan     chan struct{}
-	wg           sync.WaitGroup
-	mu           sync.RWMutex
-	stats        Stats
-	startTime    time.Time
+	config           *Config
+	configPath       string
+	sources          []Source
+	beforeLoad       BeforeLoadFunc
+	hookProcess      *os.Process
+	paused           bool
+	restartRequested bool
+	cancel           context.CancelFunc
+	wg               sync.WaitGroup
+	mu               sync.RWMutex
+	stats            Stats
+	startTime        time.Time
+
+	// reloadMu serializes Reload so an API-triggered reload (PUT
+	// /v1/config) and the watcher-triggered one its own file write
+	// fires in watchConfig can't run concurrently and race each other's
+	// merge/IPC push.
+	reloadMu sync.Mutex
+
+	// IPC connection to the running hook process, used to push config
+	// and pause/resume without restarting it. Guarded by ipcMu rather
+	// than mu since it is written from the accept loop independently
+	// of config state.
+	ipcSockPath string
+	ipcConn     net.Conn
+	ipcMu       sync.RWMutex
+
+	// Notification fan-out; publish() is non-blocking so a slow sink
+	// (e.g. a webhook) never stalls the caller.
+	notifyCh      chan Notification
+	notifiers     []Notifier
+	notifyLimiter *rateLimiter
+
+	// Per-subsystem structured loggers, rebuilt by refreshLoggers on
+	// every config load.
+	loggers map[string]hclog.Logger
 }
 
-// Config represents the SmartType configuration
+// Config represents the SmartType configuration. Enabled, SmartPunctuation
+// and Autocorrect are *bool (rather than bool) so a layered Source (env,
+// conf.d) can tell "explicitly set to false" apart from "not mentioned at
+// all" when mergeConfig layers it over a lower-priority source; see
+// boolValue.
+// Config is shared across three wire formats: the YAML file on disk, the
+// control API's JSON (GET/PUT /v1/config, /v1/apps), and the IPC payload
+// pushed to the hook. The json tags are kept identical to the yaml ones so
+// all three agree on field names.
 type Config struct {
-	Enabled          bool                       `yaml:"enabled"`
-	SmartPunctuation bool                       `yaml:"smart_punctuation"`
-	Autocorrect      bool                       `yaml:"autocorrect"`
-	MinWordLength    int                        `yaml:"min_word_length"`
-	Applications     map[string]AppConfig       `yaml:"applications"`
-	CustomTypos      map[string]string          `yaml:"custom_typos"`
-	Hotkey           string                     `yaml:"hotkey"`
+	Enabled          *bool                `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	SmartPunctuation *bool                `yaml:"smart_punctuation,omitempty" json:"smart_punctuation,omitempty"`
+	Autocorrect      *bool                `yaml:"autocorrect,omitempty" json:"autocorrect,omitempty"`
+	MinWordLength    int                  `yaml:"min_word_length" json:"min_word_length"`
+	Applications     map[string]AppConfig `yaml:"applications" json:"applications"`
+	CustomTypos      map[string]string    `yaml:"custom_typos" json:"custom_typos"`
+	Hotkey           string               `yaml:"hotkey" json:"hotkey"`
+	// Include names glob patterns, resolved against
+	// ~/.config/smarttype/conf.d, whose matching YAML files are merged
+	// on top of this one.
+	Include       []string           `yaml:"include,omitempty" json:"include,omitempty"`
+	Notifications NotificationConfig `yaml:"notifications,omitempty" json:"notifications,omitempty"`
+	Logging       LoggingConfig      `yaml:"logging,omitempty" json:"logging,omitempty"`
 }
 
-// AppConfig represents per-application configuration
+// AppConfig represents per-application configuration. All three fields are
+// *bool for the same reason as Config's: a conf.d snippet overriding one
+// field of an app must leave the app's other fields alone rather than
+// resetting them to zero values.
 type AppConfig struct {
-	Enabled     bool  `yaml:"enabled"`
-	SmartQuotes *bool `yaml:"smart_quotes,omitempty"`
-	Autocorrect *bool `yaml:"autocorrect,omitempty"`
+	Enabled     *bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	SmartQuotes *bool `yaml:"smart_quotes,omitempty" json:"smart_quotes,omitempty"`
+	Autocorrect *bool `yaml:"autocorrect,omitempty" json:"autocorrect,omitempty"`
+}
+
+// boolValue dereferences p, falling back to def when p is nil (the field
+// was never explicitly set by any layered config source).
+func boolValue(p *bool, def bool) bool {
+	if p == nil {
+		return def
+	}
+	return *p
 }
 
 // Stats tracks daemon statistics
@@ -58,107 +119,200 @@ func NewService() *Service {
 	configPath := filepath.Join(homeDir, ".config", "smarttype", "config.yaml")
 
 	return &Service{
-		configPath: configPath,
-		stopChan:   make(chan struct{}),
-		startTime:  time.Now(),
+		configPath:  configPath,
+		startTime:   time.Now(),
+		ipcSockPath: ipcSocketPath(),
+		notifyCh:    make(chan Notification, 64),
 	}
 }
 
-// Start initializes and starts the service
-func (s *Service) Start() error {
-	log.Println("Starting SmartType service...")
+// RegisterBeforeLoad installs fn to run before every config reload,
+// given the chance to reject the transition (e.g. a MinWordLength that
+// would drop below 1). It must be called before Start.
+func (s *Service) RegisterBeforeLoad(fn BeforeLoadFunc) {
+	s.beforeLoad = fn
+}
+
+// checkBeforeLoad runs the registered BeforeLoadFunc, if any, against the
+// config currently in effect and the given candidate. Callers that build
+// new outside of loadConfig's own file/source merge (e.g. the control
+// API's PUT /v1/config) must call this before swapping new in, since
+// BeforeLoadFunc only makes sense gating the transition from what is
+// actually live right now.
+func (s *Service) checkBeforeLoad(new *Config) error {
+	if s.beforeLoad == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	old := s.config
+	s.mu.RUnlock()
+
+	if old == nil {
+		return nil
+	}
+	return s.beforeLoad(old, new)
+}
+
+// Start initializes the service and runs its supervised children (the
+// hook process and the config watcher) until ctx is cancelled or Stop is
+// called.
+func (s *Service) Start(ctx context.Context) error {
+	s.log(subsystemService).Info("starting SmartType service")
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
 
 	// Load configuration
 	if err := s.loadConfig(); err != nil {
+		cancel()
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Setup file watcher for config changes
-	if err := s.setupWatcher(); err != nil {
-		return fmt.Errorf("failed to setup config watcher: %w", err)
-	}
+	// Run supervised children
+	s.wg.Add(1)
+	go s.watchConfig(ctx)
 
-	// Start the input hook process
-	if err := s.startHook(); err != nil {
-		return fmt.Errorf("failed to start input hook: %w", err)
-	}
+	s.wg.Add(1)
+	go s.superviseHook(ctx)
+
+	s.wg.Add(1)
+	go s.serveIPC(ctx)
 
-	// Start background tasks
 	s.wg.Add(1)
-	go s.watchConfig()
+	go s.serveAPI(ctx)
 
-	log.Println("SmartType service started successfully")
+	s.wg.Add(1)
+	go s.runNotifications(ctx)
+
+	s.log(subsystemService).Info("SmartType service started successfully")
+	s.publish(EventStartup, "SmartType started", "")
 	return nil
 }
 
-// Stop gracefully shuts down the service
-func (s *Service) Stop() {
-	log.Println("Stopping SmartType service...")
+// Stop gracefully shuts down the service, cancelling its context and
+// waiting for supervised children to exit. If ctx is done before they
+// finish, Stop returns without waiting further.
+func (s *Service) Stop(ctx context.Context) {
+	s.log(subsystemService).Info("stopping SmartType service")
+	s.publish(EventShutdown, "SmartType stopping", "")
 
-	close(s.stopChan)
-
-	// Stop the hook process
-	if s.hookProcess != nil {
-		s.hookProcess.Signal(os.Interrupt)
-		s.hookProcess.Wait()
+	if s.cancel != nil {
+		s.cancel()
 	}
 
-	// Stop file watcher
-	if s.watcher != nil {
-		s.watcher.Close()
+	// Source watches and the hook process are stopped by watchConfig
+	// and superviseHook themselves in response to context cancellation.
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.log(subsystemService).Warn("shutdown deadline exceeded, exiting anyway")
 	}
 
-	s.wg.Wait()
-	log.Println("SmartType service stopped")
+	s.log(subsystemService).Info("SmartType service stopped")
 }
 
-// Reload reloads the configuration
-func (s *Service) Reload() error {
-	log.Println("Reloading configuration...")
+// Reload reloads the configuration and pushes it to the hook over IPC,
+// restarting the hook process only if the push fails. It is serialized against other Reload calls:
+// an API-triggered reload (PUT /v1/config) writes the base file and
+// then calls Reload itself, which the fsnotify watcher in watchConfig
+// also observes and reloads for independently, so without this lock
+// the two could run concurrently and race each other's merge/IPC push.
+func (s *Service) Reload(ctx context.Context) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	s.log(subsystemService).Info("reloading configuration")
 
 	if err := s.loadConfig(); err != nil {
+		s.publish(EventReloadFailed, "Config reload failed", err.Error())
 		return fmt.Errorf("failed to reload config: %w", err)
 	}
 
-	// Restart hook process with new config
-	if s.hookProcess != nil {
-		s.hookProcess.Signal(os.Interrupt)
-		s.hookProcess.Wait()
-	}
+	// Prefer pushing the new config to the hook over IPC; this avoids
+	// the input-drop window of a restart. Fall back to a restart if no
+	// hook is currently connected.
+	s.mu.RLock()
+	cfg := s.config
+	s.mu.RUnlock()
 
-	if err := s.startHook(); err != nil {
-		return fmt.Errorf("failed to restart hook: %w", err)
+	if err := s.pushIPC(ipcMessage{Type: "config", Payload: cfg}); err != nil {
+		if !errors.Is(err, errIPCNotConnected) {
+			s.log(subsystemIPC).Warn("failed to push config over IPC, restarting hook instead", "error", err)
+		}
+		s.restartHook()
 	}
 
 	s.mu.Lock()
 	s.stats.LastReload = time.Now()
 	s.mu.Unlock()
 
-	log.Println("Configuration reloaded successfully")
+	s.log(subsystemService).Info("configuration reloaded successfully")
 	return nil
 }
 
-// loadConfig loads configuration from file
+// loadConfig loads configuration from the base file, merges in any
+// conf.d includes it names and the SMARTTYPE_* environment overlay (in
+// that priority order), and swaps it in after BeforeLoad approves the
+// transition.
 func (s *Service) loadConfig() error {
-	data, err := os.ReadFile(s.configPath)
+	base := newFileSource(s.configPath)
+
+	data, err := base.Load(context.Background())
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Create default config
-			s.config = s.defaultConfig()
-			return s.saveConfig()
-		}
 		return err
 	}
+	if data == nil {
+		// Create default config
+		s.mu.Lock()
+		s.config = s.defaultConfig()
+		s.mu.Unlock()
+		s.refreshLoggers()
+		s.refreshNotifiers()
+		return s.saveConfig()
+	}
 
-	config := &Config{}
-	if err := yaml.Unmarshal(data, config); err != nil {
-		return err
+	merged := &Config{}
+	if err := yaml.Unmarshal(data, merged); err != nil {
+		return fmt.Errorf("%s: %w", base.Name(), err)
+	}
+
+	sources := confDIncludes(s.configPath, merged.Include)
+	sources = append(sources, envSource{})
+
+	for _, src := range sources {
+		raw, err := src.Load(context.Background())
+		if err != nil {
+			return fmt.Errorf("%s: %w", src.Name(), err)
+		}
+		if raw == nil {
+			continue
+		}
+		overlay := &Config{}
+		if err := yaml.Unmarshal(raw, overlay); err != nil {
+			return fmt.Errorf("%s: %w", src.Name(), err)
+		}
+		mergeConfig(merged, overlay)
+	}
+
+	if err := s.checkBeforeLoad(merged); err != nil {
+		return fmt.Errorf("rejected config: %w", err)
 	}
 
 	s.mu.Lock()
-	s.config = config
+	s.config = merged
+	s.sources = sources
 	s.mu.Unlock()
 
+	s.refreshLoggers()
+	s.refreshNotifiers()
+
 	return nil
 }
 
@@ -184,23 +338,23 @@ func (s *Service) defaultConfig() *Config {
 	falseVal := false
 
 	return &Config{
-		Enabled:          true,
-		SmartPunctuation: true,
-		Autocorrect:      true,
+		Enabled:          &trueVal,
+		SmartPunctuation: &trueVal,
+		Autocorrect:      &trueVal,
 		MinWordLength:    2,
 		Applications: map[string]AppConfig{
 			"firefox": {
-				Enabled:     true,
+				Enabled:     &trueVal,
 				SmartQuotes: &trueVal,
 				Autocorrect: &trueVal,
 			},
 			"qterminal": {
-				Enabled:     true,
+				Enabled:     &trueVal,
 				SmartQuotes: &falseVal,
 				Autocorrect: &trueVal,
 			},
 			"kitty": {
-				Enabled:     true,
+				Enabled:     &trueVal,
 				SmartQuotes: &falseVal,
 				Autocorrect: &trueVal,
 			},
@@ -213,69 +367,60 @@ func (s *Service) defaultConfig() *Config {
 	}
 }
 
-// setupWatcher sets up file system watcher for config changes
-func (s *Service) setupWatcher() error {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return err
-	}
-
-	s.watcher = watcher
-
-	// Watch config directory
-	configDir := filepath.Dir(s.configPath)
-	if err := watcher.Add(configDir); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// watchConfig watches for configuration file changes
-func (s *Service) watchConfig() {
+// watchConfig fans in change notifications from every active config
+// source (the base file, its conf.d includes, ...) and triggers a
+// reload whenever one of them fires. The source list is re-read after
+// every reload so newly-added conf.d snippets start being watched
+// without a daemon restart.
+func (s *Service) watchConfig(ctx context.Context) {
 	defer s.wg.Done()
 
 	for {
+		s.mu.RLock()
+		sources := append([]Source{newFileSource(s.configPath)}, s.sources...)
+		s.mu.RUnlock()
+
+		changed, stop := s.fanInWatch(ctx, sources)
+
 		select {
-		case event := <-s.watcher.Events:
-			if event.Name == s.configPath && event.Op&fsnotify.Write == fsnotify.Write {
-				log.Println("Config file changed, reloading...")
-				if err := s.Reload(); err != nil {
-					log.Printf("Error reloading config: %v", err)
-				}
+		case <-changed:
+			stop()
+			s.log(subsystemWatcher).Info("config source changed, reloading")
+			if err := s.Reload(ctx); err != nil {
+				s.log(subsystemWatcher).Error("reload failed", "error", err)
 			}
-		case err := <-s.watcher.Errors:
-			log.Printf("Watcher error: %v", err)
-		case <-s.stopChan:
+		case <-ctx.Done():
+			stop()
 			return
 		}
 	}
 }
 
-// startHook starts the input hook process
-func (s *Service) startHook() error {
-	if !s.config.Enabled {
-		log.Println("SmartType is disabled in config, not starting hook")
-		return nil
-	}
-
-	// Find hook binary
-	hookPath := "/usr/local/bin/smarttype-hook"
-	if _, err := os.Stat(hookPath); os.IsNotExist(err) {
-		hookPath = "./target/release/smarttype-hook"
-	}
-
-	cmd := exec.Command(hookPath)
-	cmd.Env = append(os.Environ(), "RUST_LOG=info")
-
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start hook process: %w", err)
+// fanInWatch starts a Watch on each source and merges their channels
+// into one. The returned stop func cancels all of them.
+func (s *Service) fanInWatch(ctx context.Context, sources []Source) (<-chan struct{}, func()) {
+	watchCtx, cancel := context.WithCancel(ctx)
+	out := make(chan struct{}, 1)
+
+	for _, src := range sources {
+		ch, err := src.Watch(watchCtx)
+		if err != nil {
+			s.log(subsystemWatcher).Error("failed to watch source", "source", src.Name(), "error", err)
+			continue
+		}
+		go func(ch <-chan struct{}) {
+			select {
+			case <-ch:
+				select {
+				case out <- struct{}{}:
+				default:
+				}
+			case <-watchCtx.Done():
+			}
+		}(ch)
 	}
 
-	s.hookProcess = cmd.Process
-	log.Printf("Input hook started (PID: %d)", s.hookProcess.Pid)
-
-	return nil
+	return out, cancel
 }
 
 // GetStats returns current statistics
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+// TestCheckBeforeLoadSeesLiveConfig guards against regressing to comparing
+// a candidate config against itself: checkBeforeLoad must gate against
+// whatever s.config actually is right now, not a copy already overwritten
+// by the caller.
+func TestCheckBeforeLoadSeesLiveConfig(t *testing.T) {
+	s := &Service{config: &Config{MinWordLength: 5}}
+
+	var sawOld, sawNew *Config
+	s.RegisterBeforeLoad(func(old, new *Config) error {
+		sawOld, sawNew = old, new
+		return nil
+	})
+
+	candidate := &Config{MinWordLength: 1}
+	if err := s.checkBeforeLoad(candidate); err != nil {
+		t.Fatalf("checkBeforeLoad returned error: %v", err)
+	}
+
+	if sawOld == nil || sawOld.MinWordLength != 5 {
+		t.Errorf("BeforeLoadFunc saw old.MinWordLength = %v, want 5 (the config before this request)", sawOld)
+	}
+	if sawNew != candidate {
+		t.Errorf("BeforeLoadFunc did not see the candidate config")
+	}
+	if sawOld == sawNew {
+		t.Errorf("old and new must not be the same config, or rejection logic can never see a real transition")
+	}
+}
+
+func TestCheckBeforeLoadRejects(t *testing.T) {
+	s := &Service{config: &Config{MinWordLength: 5}}
+	s.RegisterBeforeLoad(func(old, new *Config) error {
+		return validateConfig(new)
+	})
+
+	if err := s.checkBeforeLoad(&Config{MinWordLength: 0}); err == nil {
+		t.Error("expected checkBeforeLoad to reject MinWordLength 0")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Backoff bounds for restarting a crashed input hook process.
+const (
+	hookMinBackoff    = 1 * time.Second
+	hookMaxBackoff    = 30 * time.Second
+	hookHealthyUptime = 60 * time.Second
+)
+
+// errHookDisabled is returned by spawnHook when SmartType is disabled in
+// the current config, or paused via the control API; it is not treated
+// as a crash by superviseHook.
+var errHookDisabled = errors.New("smarttype: hook disabled or paused")
+
+// superviseHook runs the input hook process for the lifetime of ctx,
+// restarting it with exponential backoff if it exits unexpectedly. The
+// backoff resets to hookMinBackoff once a run stays up for
+// hookHealthyUptime. Exits we asked for ourselves via restartHook (pause,
+// resume, config push) restart immediately with no backoff. It returns
+// when ctx is cancelled.
+func (s *Service) superviseHook(ctx context.Context) {
+	defer s.wg.Done()
+
+	backoff := hookMinBackoff
+	for {
+		cmd, err := s.spawnHook()
+		if err != nil {
+			if !errors.Is(err, errHookDisabled) {
+				s.log(subsystemHook).Error("failed to start input hook", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = nextHookBackoff(backoff)
+			continue
+		}
+
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+
+		select {
+		case <-ctx.Done():
+			cmd.Process.Signal(os.Interrupt)
+			<-done
+			return
+		case err := <-done:
+			s.mu.Lock()
+			s.hookProcess = nil
+			requested := s.restartRequested
+			s.restartRequested = false
+			s.mu.Unlock()
+
+			if time.Since(start) >= hookHealthyUptime {
+				backoff = hookMinBackoff
+			}
+
+			if requested {
+				// We asked for this exit ourselves (pause/resume/config push
+				// via restartHook); restart immediately rather than paying
+				// crash backoff, and don't treat it as a crash.
+				s.log(subsystemHook).Info("input hook restarting", "uptime", time.Since(start).Round(time.Second).String())
+				backoff = hookMinBackoff
+				continue
+			}
+
+			s.log(subsystemHook).Warn("input hook exited unexpectedly", "uptime", time.Since(start).Round(time.Second).String(), "error", err, "backoff", backoff.String())
+			s.publish(EventHookCrashed, "Input hook crashed", fmt.Sprintf("exited after %s: %v", time.Since(start).Round(time.Second), err))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = nextHookBackoff(backoff)
+		}
+	}
+}
+
+func nextHookBackoff(b time.Duration) time.Duration {
+	b *= 2
+	if b > hookMaxBackoff {
+		b = hookMaxBackoff
+	}
+	return b
+}
+
+// spawnHook starts one instance of the input hook process. The caller is
+// responsible for waiting on the returned *exec.Cmd.
+func (s *Service) spawnHook() (*exec.Cmd, error) {
+	s.mu.RLock()
+	enabled := boolValue(s.config.Enabled, false) && !s.paused
+	s.mu.RUnlock()
+	if !enabled {
+		return nil, errHookDisabled
+	}
+
+	// Find hook binary
+	hookPath := "/usr/local/bin/smarttype-hook"
+	if _, err := os.Stat(hookPath); os.IsNotExist(err) {
+		hookPath = "./target/release/smarttype-hook"
+	}
+
+	cmd := exec.Command(hookPath)
+	cmd.Env = append(os.Environ(), "RUST_LOG=info", "SMARTTYPE_IPC_SOCK="+s.ipcSockPath)
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.hookProcess = cmd.Process
+	s.mu.Unlock()
+
+	s.log(subsystemHook).Info("input hook started", "pid", cmd.Process.Pid)
+	return cmd, nil
+}
+
+// restartHook asks the currently running hook process to exit so that
+// superviseHook picks the restart up with the latest config. It is a
+// no-op if no hook is running.
+func (s *Service) restartHook() {
+	s.mu.Lock()
+	hookProcess := s.hookProcess
+	if hookProcess != nil {
+		s.restartRequested = true
+	}
+	s.mu.Unlock()
+
+	if hookProcess != nil {
+		hookProcess.Signal(os.Interrupt)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextHookBackoff(t *testing.T) {
+	cases := []struct {
+		in   time.Duration
+		want time.Duration
+	}{
+		{hookMinBackoff, 2 * time.Second},
+		{10 * time.Second, 20 * time.Second},
+		{20 * time.Second, hookMaxBackoff},
+		{hookMaxBackoff, hookMaxBackoff},
+	}
+
+	for _, c := range cases {
+		if got := nextHookBackoff(c.in); got != c.want {
+			t.Errorf("nextHookBackoff(%s) = %s, want %s", c.in, got, c.want)
+		}
+	}
+}